@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2016-2017 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package smux
+
+import "time"
+
+// cmdRST extends the frame command set defined in frame.go: it is sent
+// alongside a forced close so the peer can release its side immediately
+// instead of waiting on its own StreamCloseTimeout.
+const cmdRST byte = 0x83
+
+// armStreamCloseTimeout starts (or restarts) a timer for sid: if the stream
+// is not fully closed and removed from s.streams by the time it fires, the
+// stream is force-closed and the peer is told to release it too. Called
+// whenever a stream enters a half-closed state (we received the peer's FIN
+// but haven't closed our side, or we closed our side and are waiting on the
+// peer's FIN). A sid no longer in s.streams already went through
+// streamClosed/forceCloseStream, so arming for it would be a stray no-op
+// timer; skip it.
+func (s *Session) armStreamCloseTimeout(sid uint32) {
+	if s.config.StreamCloseTimeout <= 0 {
+		return
+	}
+
+	s.streamLock.Lock()
+	_, ok := s.streams[sid]
+	s.streamLock.Unlock()
+	if !ok {
+		return
+	}
+
+	s.streamCloseTimerLock.Lock()
+	if s.streamCloseTimers == nil {
+		s.streamCloseTimers = make(map[uint32]*time.Timer)
+	}
+	if t, ok := s.streamCloseTimers[sid]; ok {
+		t.Stop()
+	}
+	s.streamCloseTimers[sid] = time.AfterFunc(s.config.StreamCloseTimeout, func() {
+		s.forceCloseStream(sid)
+	})
+	s.streamCloseTimerLock.Unlock()
+}
+
+// cancelStreamCloseTimeout stops and forgets the timer for sid, if any. It
+// is called once the stream is fully closed through the normal path.
+func (s *Session) cancelStreamCloseTimeout(sid uint32) {
+	s.streamCloseTimerLock.Lock()
+	if t, ok := s.streamCloseTimers[sid]; ok {
+		t.Stop()
+		delete(s.streamCloseTimers, sid)
+	}
+	s.streamCloseTimerLock.Unlock()
+}
+
+// stopAllStreamCloseTimeouts stops every pending timer, used on session shutdown.
+func (s *Session) stopAllStreamCloseTimeouts() {
+	s.streamCloseTimerLock.Lock()
+	for sid, t := range s.streamCloseTimers {
+		t.Stop()
+		delete(s.streamCloseTimers, sid)
+	}
+	s.streamCloseTimerLock.Unlock()
+}
+
+// forceCloseStream is invoked once a half-closed stream's StreamCloseTimeout
+// has elapsed. It releases our side of the stream and tells the peer to do
+// the same via cmdFIN/cmdRST, rather than leaving the stream pinning tokens
+// in the receive bucket forever.
+func (s *Session) forceCloseStream(sid uint32) {
+	s.streamLock.Lock()
+	stream, ok := s.streams[sid]
+	s.streamLock.Unlock()
+	if !ok {
+		return
+	}
+
+	stream.sessionClose()
+	s.streamClosed(sid)
+	s.writeFrameInternal(newFrame(byte(s.config.Version), cmdFIN, sid), nil, CLSCTRL)
+	s.writeFrameInternal(newFrame(byte(s.config.Version), cmdRST, sid), nil, CLSCTRL)
+}
@@ -0,0 +1,104 @@
+// MIT License
+//
+// Copyright (c) 2016-2017 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package smux
+
+import (
+	"errors"
+	"time"
+)
+
+// Config is used to tune the Session's behavior.
+type Config struct {
+	// Version is the protocol version, negotiated out of band by both peers.
+	Version int
+
+	// KeepAliveDisabled disables the keepalive probe.
+	KeepAliveDisabled bool
+
+	// KeepAliveInterval is how often to send a keepalive ping.
+	KeepAliveInterval time.Duration
+
+	// KeepAliveTimeout is how long a session may go without an acknowledged
+	// ping before it is considered dead and closed.
+	KeepAliveTimeout time.Duration
+
+	// MaxFrameSize is the maximum size of a data frame.
+	MaxFrameSize int
+
+	// MaxReceiveBuffer is the session-wide token bucket size: the total
+	// number of bytes the session will buffer across all streams before it
+	// stops reading from the underlying connection.
+	MaxReceiveBuffer int
+
+	// StreamCloseTimeout bounds how long a stream may sit half-closed (we
+	// closed our side and the peer hasn't FIN'd, or vice versa) before it is
+	// force-released so it stops pinning tokens in the receive bucket. Zero
+	// disables the timeout.
+	StreamCloseTimeout time.Duration
+
+	// MaxStreamWindowSize is the per-stream flow-control window advertised
+	// to the peer via cmdUPD, on top of the session-wide MaxReceiveBuffer.
+	// Zero disables per-stream window advertisement, leaving flow control
+	// to the global token bucket alone.
+	MaxStreamWindowSize uint32
+}
+
+// DefaultConfig returns the default configuration used if none is provided.
+func DefaultConfig() *Config {
+	return &Config{
+		Version:            1,
+		KeepAliveInterval:  10 * time.Second,
+		KeepAliveTimeout:   30 * time.Second,
+		MaxFrameSize:       32768,
+		MaxReceiveBuffer:   4194304,
+		StreamCloseTimeout: 5 * time.Minute,
+	}
+}
+
+// VerifyConfig verifies the sanity of configuration.
+func VerifyConfig(config *Config) error {
+	if config.Version != 1 {
+		return errors.New("unsupported protocol version")
+	}
+	if !config.KeepAliveDisabled {
+		if config.KeepAliveInterval <= 0 {
+			return errors.New("keep-alive interval must be positive")
+		}
+		if config.KeepAliveTimeout <= config.KeepAliveInterval {
+			return errors.New("keep-alive timeout must be larger than keep-alive interval")
+		}
+	}
+	if config.MaxFrameSize <= 0 {
+		return errors.New("max frame size must be positive")
+	}
+	if config.MaxFrameSize > 65535 {
+		return errors.New("max frame size too large")
+	}
+	if config.MaxReceiveBuffer <= 0 {
+		return errors.New("max receive buffer must be positive")
+	}
+	if config.StreamCloseTimeout < 0 {
+		return errors.New("stream close timeout must not be negative")
+	}
+	return nil
+}
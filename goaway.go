@@ -0,0 +1,60 @@
+// MIT License
+//
+// Copyright (c) 2016-2017 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package smux
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+)
+
+// cmdGOAWAY extends the frame command set defined in frame.go: it tells the
+// peer to stop opening new streams on this session, carrying a 4-byte
+// reason code as its payload.
+const cmdGOAWAY byte = 0x82
+
+// GoAway reason codes, carried as the payload of a cmdGOAWAY frame.
+const (
+	GoAwayNormal uint32 = iota
+	GoAwayProtocolError
+	GoAwayInternalError
+)
+
+const goAwayPayloadSize = 4
+
+// GoAway tells the peer to stop opening new streams on this session. It only
+// sends the frame: this session still accepts any SYN that arrives, and it
+// is not closed. Streams already open keep working until they close
+// naturally.
+func (s *Session) GoAway(reason uint32) error {
+	payload := make([]byte, goAwayPayloadSize)
+	binary.LittleEndian.PutUint32(payload, reason)
+	frame := newFrame(byte(s.config.Version), cmdGOAWAY, 0)
+	frame.data = payload
+	_, err := s.writeControlFrame(frame)
+	return err
+}
+
+// handleGoAway records that the peer asked us to stop opening new streams.
+func (s *Session) handleGoAway(reason uint32) {
+	atomic.StoreInt32(&s.remoteGoAway, 1)
+}
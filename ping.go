@@ -0,0 +1,125 @@
+// MIT License
+//
+// Copyright (c) 2016-2017 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package smux
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cmdPING and cmdPONG extend the frame command set defined in frame.go
+// with a lightweight round-trip probe: cmdPING carries a 4-byte ping id
+// in its payload, and the peer echoes it back unchanged as cmdPONG.
+const (
+	cmdPING byte = 0x80 + iota
+	cmdPONG
+)
+
+// pingPayloadSize is the size of the ping id carried in a cmdPING/cmdPONG frame.
+const pingPayloadSize = 4
+
+// pings tracks in-flight Ping() calls, keyed by ping id, so recvLoop can
+// wake the waiter when the matching PONG arrives.
+type pings struct {
+	sync.Mutex
+	nextID  uint32
+	waiting map[uint32]chan struct{}
+}
+
+// Ping sends a PING frame to the peer and blocks until the matching PONG
+// is received, returning the measured round-trip time.
+func (s *Session) Ping() (time.Duration, error) {
+	return s.PingContext(context.Background())
+}
+
+// PingContext is like Ping but allows the caller to bound the wait with a context.
+func (s *Session) PingContext(ctx context.Context) (time.Duration, error) {
+	if s.IsClosed() {
+		return 0, io.ErrClosedPipe
+	}
+
+	id := atomic.AddUint32(&s.pings.nextID, 1)
+	wait := make(chan struct{})
+
+	s.pings.Lock()
+	s.pings.waiting[id] = wait
+	s.pings.Unlock()
+
+	defer func() {
+		s.pings.Lock()
+		delete(s.pings.waiting, id)
+		s.pings.Unlock()
+	}()
+
+	payload := make([]byte, pingPayloadSize)
+	binary.LittleEndian.PutUint32(payload, id)
+	frame := newFrame(byte(s.config.Version), cmdPING, 0)
+	frame.data = payload
+
+	sentAt := time.Now()
+	if _, err := s.writeControlFrame(frame); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-wait:
+		return time.Since(sentAt), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-s.chSocketReadError:
+		return 0, s.socketReadError.Load().(error)
+	case <-s.chSocketWriteError:
+		return 0, s.socketWriteError.Load().(error)
+	case <-s.die:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// handlePong delivers a received PONG to the waiter with the matching ping id, if any.
+// The raw cmdPONG traffic itself is already counted by frameCounters.recordRecv,
+// called from recvLoop for every frame before this handler runs.
+func (s *Session) handlePong(id uint32) {
+	s.pings.Lock()
+	if wait, ok := s.pings.waiting[id]; ok {
+		close(wait)
+		delete(s.pings.waiting, id)
+	}
+	s.pings.Unlock()
+}
+
+// handlePing replies to a received PING with a PONG echoing the same id.
+// Called from recvLoop, so the reply is dispatched on its own goroutine:
+// writeControlFrame blocks until sendLoop completes a real conn.Write, and
+// a backpressured outbound path must not stall the goroutine draining the
+// socket.
+func (s *Session) handlePing(id uint32) {
+	payload := make([]byte, pingPayloadSize)
+	binary.LittleEndian.PutUint32(payload, id)
+	frame := newFrame(byte(s.config.Version), cmdPONG, 0)
+	frame.data = payload
+	go s.writeControlFrame(frame)
+}
@@ -24,6 +24,7 @@ package smux
 
 import (
 	"container/heap"
+	"context"
 	"encoding/binary"
 	"errors"
 	"io"
@@ -116,9 +117,21 @@ type Session struct {
 
 	chAccepts chan *stream
 
-	dataReady int32 // flag data has arrived
+	goAway       int32 // flag id exhausted
+	remoteGoAway int32 // flag peer asked us to stop opening new streams
 
-	goAway int32 // flag id exhausted
+	pings pings // in-flight Ping()/PingContext() calls, keyed by ping id
+
+	lastPingAck atomic.Value // time.Time of the last acknowledged ping, used by keepalive
+
+	streamCloseTimers    map[uint32]*time.Timer // force-close timers for half-closed streams
+	streamCloseTimerLock sync.Mutex
+
+	establishing  map[uint32]chan struct{} // streams awaiting their peer's SYNACK, keyed by sid
+	establishLock sync.Mutex
+
+	frameCounters   frameCounters // per-cmd send/recv counters, see Stats()
+	shaperQueueSize int32         // write requests currently queued in shaperLoop
 
 	deadline atomic.Value
 
@@ -141,6 +154,8 @@ func newSession(config *Config, conn io.ReadWriteCloser, client bool) *Session {
 	s.chSocketReadError = make(chan struct{})
 	s.chSocketWriteError = make(chan struct{})
 	s.chProtoError = make(chan struct{})
+	s.pings.waiting = make(map[uint32]chan struct{})
+	s.lastPingAck.Store(time.Now())
 
 	if client {
 		s.nextStreamID = 1
@@ -157,54 +172,13 @@ func newSession(config *Config, conn io.ReadWriteCloser, client bool) *Session {
 	return s
 }
 
-// OpenStream is used to create a new stream
+// OpenStream is used to create a new stream. It waits for the peer's
+// SYNACK, bounded by a default openCloseTimeout; use OpenStreamContext to
+// control how long to wait.
 func (s *Session) OpenStream() (*Stream, error) {
-	if s.IsClosed() {
-		return nil, io.ErrClosedPipe
-	}
-
-	// generate stream id
-	s.nextStreamIDLock.Lock()
-	if s.goAway > 0 {
-		s.nextStreamIDLock.Unlock()
-		return nil, ErrGoAway
-	}
-
-	s.nextStreamID += 2
-	sid := s.nextStreamID
-	if sid == sid%2 { // stream-id overflows
-		s.goAway = 1
-		s.nextStreamIDLock.Unlock()
-		return nil, ErrGoAway
-	}
-	s.nextStreamIDLock.Unlock()
-
-	stream := newStream(sid, s.config.MaxFrameSize, s)
-
-	if _, err := s.writeControlFrame(newFrame(byte(s.config.Version), cmdSYN, sid)); err != nil {
-		return nil, err
-	}
-
-	s.streamLock.Lock()
-	defer s.streamLock.Unlock()
-	select {
-	case <-s.chSocketReadError:
-		return nil, s.socketReadError.Load().(error)
-	case <-s.chSocketWriteError:
-		return nil, s.socketWriteError.Load().(error)
-	case <-s.die:
-		return nil, io.ErrClosedPipe
-	default:
-		s.streams[sid] = stream
-		wrapper := &Stream{stream: stream}
-		// NOTE(x): disabled finalizer for issue #997
-		/*
-			runtime.SetFinalizer(wrapper, func(s *Stream) {
-				s.Close()
-			})
-		*/
-		return wrapper, nil
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), openCloseTimeout)
+	defer cancel()
+	return s.OpenStreamContext(ctx)
 }
 
 // Open returns a generic ReadWriteCloser
@@ -259,6 +233,7 @@ func (s *Session) Close() error {
 			s.streams[k].sessionClose()
 		}
 		s.streamLock.Unlock()
+		s.stopAllStreamCloseTimeouts()
 		return s.conn.Close()
 	} else {
 		return io.ErrClosedPipe
@@ -366,6 +341,7 @@ func (s *Session) streamClosed(sid uint32) {
 		delete(s.streams, sid)
 	}
 	s.streamLock.Unlock()
+	s.cancelStreamCloseTimeout(sid)
 }
 
 // returnTokens is called by stream to return token after read
@@ -391,25 +367,69 @@ func (s *Session) recvLoop() {
 
 		// read header first
 		if _, err := io.ReadFull(s.conn, hdr[:]); err == nil {
-			atomic.StoreInt32(&s.dataReady, 1)
 			if hdr.Version() != byte(s.config.Version) {
 				s.notifyProtoError(ErrInvalidProtocol)
 				return
 			}
 			sid := hdr.StreamID()
+			s.frameCounters.recordRecv(hdr.Cmd(), int(hdr.Length()))
 			switch hdr.Cmd() {
 			case cmdNOP:
+			case cmdPING: // ping request, echo back as a pong
+				var payload [pingPayloadSize]byte
+				if _, err := io.ReadFull(s.conn, payload[:]); err == nil {
+					s.handlePing(binary.LittleEndian.Uint32(payload[:]))
+				} else {
+					s.notifyReadError(err)
+					return
+				}
+			case cmdPONG: // ping reply, wake up the waiting Ping() caller
+				var payload [pingPayloadSize]byte
+				if _, err := io.ReadFull(s.conn, payload[:]); err == nil {
+					s.lastPingAck.Store(time.Now())
+					s.handlePong(binary.LittleEndian.Uint32(payload[:]))
+				} else {
+					s.notifyReadError(err)
+					return
+				}
+			case cmdGOAWAY: // peer is draining, stop opening new streams
+				var payload [goAwayPayloadSize]byte
+				if _, err := io.ReadFull(s.conn, payload[:]); err == nil {
+					s.handleGoAway(binary.LittleEndian.Uint32(payload[:]))
+				} else {
+					s.notifyReadError(err)
+					return
+				}
 			case cmdSYN: // stream opening
 				s.streamLock.Lock()
+				delivered := false
 				if _, ok := s.streams[sid]; !ok {
 					stream := newStream(sid, s.config.MaxFrameSize, s)
 					s.streams[sid] = stream
 					select {
 					case s.chAccepts <- stream:
+						delivered = true
 					case <-s.die:
 					}
 				}
 				s.streamLock.Unlock()
+				// Only ack a stream we actually created and handed to an
+				// acceptor: a retransmitted SYN for an sid we already track,
+				// or one abandoned on the <-s.die branch, must not tell the
+				// peer a stream is "established" that either wasn't newly
+				// opened or was never delivered.
+				if delivered {
+					// Dispatched on its own goroutine: both writes block
+					// until sendLoop completes a real conn.Write, and
+					// recvLoop must keep draining the socket even if the
+					// outbound path is backpressured.
+					go func() {
+						s.writeFrameInternal(newFrame(byte(s.config.Version), cmdSYNACK, sid), nil, CLSCTRL)
+						s.advertiseStreamWindow(sid)
+					}()
+				}
+			case cmdSYNACK: // stream established, wake the waiting OpenStreamContext caller
+				s.handleSynAck(sid)
 			case cmdFIN: // stream closing
 				s.streamLock.Lock()
 				if stream, ok := s.streams[sid]; ok {
@@ -417,6 +437,16 @@ func (s *Session) recvLoop() {
 					stream.notifyReadEvent()
 				}
 				s.streamLock.Unlock()
+				// the peer closed its write side; if our side never
+				// closes, force-release the stream after StreamCloseTimeout.
+				s.armStreamCloseTimeout(sid)
+			case cmdRST: // peer force-released the stream after its own StreamCloseTimeout
+				s.streamLock.Lock()
+				if stream, ok := s.streams[sid]; ok {
+					stream.sessionClose()
+				}
+				s.streamLock.Unlock()
+				s.streamClosed(sid)
 			case cmdPSH: // data frame
 				if hdr.Length() > 0 {
 					pNewbuf := defaultAllocator.Get(int(hdr.Length()))
@@ -459,7 +489,9 @@ func (s *Session) recvLoop() {
 	}
 }
 
-// keepalive sends NOP frame to peer to keep the connection alive, and detect dead peers
+// keepalive periodically pings the peer to keep the connection alive, and
+// closes the session once the last acknowledged ping is older than
+// KeepAliveTimeout, i.e. the peer stopped answering.
 func (s *Session) keepalive() {
 	tickerPing := time.NewTicker(s.config.KeepAliveInterval)
 	tickerTimeout := time.NewTicker(s.config.KeepAliveTimeout)
@@ -468,12 +500,17 @@ func (s *Session) keepalive() {
 	for {
 		select {
 		case <-tickerPing.C:
-			s.writeFrameInternal(newFrame(byte(s.config.Version), cmdNOP, 0), tickerPing.C, CLSCTRL)
+			// fire-and-forget: the ack, if any, is recorded by recvLoop
+			// as the cmdPONG frame arrives.
+			go s.Ping()
 			s.notifyBucket() // force a signal to the recvLoop
 		case <-tickerTimeout.C:
-			if !atomic.CompareAndSwapInt32(&s.dataReady, 1, 0) {
-				// recvLoop may block while bucket is 0, in this case,
-				// session should not be closed.
+			lastAck := s.lastPingAck.Load().(time.Time)
+			if time.Since(lastAck) > s.config.KeepAliveTimeout {
+				// recvLoop may be legitimately blocked waiting for bucket
+				// tokens (a slow reader on some other stream), in which case
+				// our own PONG is simply queued unread, not lost. Only treat
+				// this as a dead peer once recvLoop is actually free to read.
 				if atomic.LoadInt32(&s.bucket) > 0 {
 					s.Close()
 					return
@@ -494,6 +531,8 @@ func (s *Session) shaperLoop() {
 	var chShaper chan writeRequest
 
 	for {
+		atomic.StoreInt32(&s.shaperQueueSize, int32(len(reqs)))
+
 		// chWrite is not available until it has packet to send
 		if len(reqs) > 0 {
 			chWrite = s.writes
@@ -567,6 +606,18 @@ func (s *Session) sendLoop() {
 				n = 0
 			}
 
+			if err == nil {
+				s.frameCounters.recordSent(request.frame.cmd, len(request.frame.data))
+				if request.frame.cmd == cmdFIN {
+					// we closed our side of request.frame.sid; if the peer
+					// never FIN's back, force-release it after
+					// StreamCloseTimeout instead of leaving it half-closed
+					// forever. A no-op if the stream already left s.streams
+					// (e.g. this FIN came from forceCloseStream itself).
+					s.armStreamCloseTimeout(request.frame.sid)
+				}
+			}
+
 			result := writeResult{
 				n:   n,
 				err: err,
@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright (c) 2016-2017 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package smux
+
+import "sync/atomic"
+
+// frameCounters holds per-command-type send/receive counts, updated
+// lock-free from recvLoop and sendLoop.
+type frameCounters struct {
+	synSent, synRecv   uint64
+	finSent, finRecv   uint64
+	pshSent, pshRecv   uint64
+	updSent, updRecv   uint64
+	nopSent, nopRecv   uint64
+	pingSent, pingRecv uint64
+	pongSent, pongRecv uint64
+
+	bytesSent, bytesRecv uint64
+}
+
+func (c *frameCounters) recordSent(cmd byte, n int) {
+	atomic.AddUint64(&c.bytesSent, uint64(n))
+	switch cmd {
+	case cmdSYN:
+		atomic.AddUint64(&c.synSent, 1)
+	case cmdFIN:
+		atomic.AddUint64(&c.finSent, 1)
+	case cmdPSH:
+		atomic.AddUint64(&c.pshSent, 1)
+	case cmdUPD:
+		atomic.AddUint64(&c.updSent, 1)
+	case cmdNOP:
+		atomic.AddUint64(&c.nopSent, 1)
+	case cmdPING:
+		atomic.AddUint64(&c.pingSent, 1)
+	case cmdPONG:
+		atomic.AddUint64(&c.pongSent, 1)
+	}
+}
+
+func (c *frameCounters) recordRecv(cmd byte, n int) {
+	atomic.AddUint64(&c.bytesRecv, uint64(n))
+	switch cmd {
+	case cmdSYN:
+		atomic.AddUint64(&c.synRecv, 1)
+	case cmdFIN:
+		atomic.AddUint64(&c.finRecv, 1)
+	case cmdPSH:
+		atomic.AddUint64(&c.pshRecv, 1)
+	case cmdUPD:
+		atomic.AddUint64(&c.updRecv, 1)
+	case cmdNOP:
+		atomic.AddUint64(&c.nopRecv, 1)
+	case cmdPING:
+		atomic.AddUint64(&c.pingRecv, 1)
+	case cmdPONG:
+		atomic.AddUint64(&c.pongRecv, 1)
+	}
+}
+
+// Stats is a point-in-time snapshot of a Session's traffic and backlog.
+// Like all other *Sent/*Recv pairs, PingSent/PingRecv and PongSent/PongRecv
+// count raw cmdPING/cmdPONG wire traffic in each direction, regardless of
+// whether it was this side or the peer that initiated the probe.
+type Stats struct {
+	SynSent, SynRecv   uint64
+	FinSent, FinRecv   uint64
+	PshSent, PshRecv   uint64
+	UpdSent, UpdRecv   uint64
+	NopSent, NopRecv   uint64
+	PingSent, PingRecv uint64
+	PongSent, PongRecv uint64
+
+	BytesSent, BytesRecv uint64
+
+	BucketAvailable int32 // tokens currently available in the receive bucket
+	NumStreams      int   // streams currently open
+	NumHalfClosed   int   // streams waiting out their StreamCloseTimeout
+	ShaperQueueSize int   // write requests currently queued in the shaper
+}
+
+// Stats returns a snapshot of this session's frame counters, byte counters,
+// token bucket fill and stream/backlog sizes. All counters are collected
+// lock-free.
+func (s *Session) Stats() Stats {
+	s.streamCloseTimerLock.Lock()
+	numHalfClosed := len(s.streamCloseTimers)
+	s.streamCloseTimerLock.Unlock()
+
+	return Stats{
+		SynSent:  atomic.LoadUint64(&s.frameCounters.synSent),
+		SynRecv:  atomic.LoadUint64(&s.frameCounters.synRecv),
+		FinSent:  atomic.LoadUint64(&s.frameCounters.finSent),
+		FinRecv:  atomic.LoadUint64(&s.frameCounters.finRecv),
+		PshSent:  atomic.LoadUint64(&s.frameCounters.pshSent),
+		PshRecv:  atomic.LoadUint64(&s.frameCounters.pshRecv),
+		UpdSent:  atomic.LoadUint64(&s.frameCounters.updSent),
+		UpdRecv:  atomic.LoadUint64(&s.frameCounters.updRecv),
+		NopSent:  atomic.LoadUint64(&s.frameCounters.nopSent),
+		NopRecv:  atomic.LoadUint64(&s.frameCounters.nopRecv),
+		PingSent: atomic.LoadUint64(&s.frameCounters.pingSent),
+		PingRecv: atomic.LoadUint64(&s.frameCounters.pingRecv),
+		PongSent: atomic.LoadUint64(&s.frameCounters.pongSent),
+		PongRecv: atomic.LoadUint64(&s.frameCounters.pongRecv),
+
+		BytesSent: atomic.LoadUint64(&s.frameCounters.bytesSent),
+		BytesRecv: atomic.LoadUint64(&s.frameCounters.bytesRecv),
+
+		BucketAvailable: atomic.LoadInt32(&s.bucket),
+		NumStreams:      s.NumStreams(),
+		NumHalfClosed:   numHalfClosed,
+		ShaperQueueSize: int(atomic.LoadInt32(&s.shaperQueueSize)),
+	}
+}
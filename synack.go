@@ -0,0 +1,138 @@
+// MIT License
+//
+// Copyright (c) 2016-2017 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package smux
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// cmdSYNACK extends the frame command set defined in frame.go: the accepting
+// side sends it back once a SYN has been pushed onto chAccepts, so the
+// opener can tell a stream was actually established from one that is still
+// racing the peer's Close. 0x80-0x83 are already taken by cmdPING, cmdPONG,
+// cmdGOAWAY and cmdRST.
+const cmdSYNACK byte = 0x84
+
+// OpenStreamContext is like OpenStream but blocks until the peer
+// acknowledges the stream with a SYNACK, or until ctx is done. Streams are
+// not considered established until the SYNACK arrives; a Write issued
+// before that point would otherwise race a peer that closed between the
+// SYN being sent and the data being queued.
+func (s *Session) OpenStreamContext(ctx context.Context) (*Stream, error) {
+	if s.IsClosed() {
+		return nil, io.ErrClosedPipe
+	}
+
+	// generate stream id
+	if atomic.LoadInt32(&s.remoteGoAway) > 0 {
+		return nil, ErrGoAway
+	}
+
+	s.nextStreamIDLock.Lock()
+	if s.goAway > 0 {
+		s.nextStreamIDLock.Unlock()
+		return nil, ErrGoAway
+	}
+	s.nextStreamID += 2
+	sid := s.nextStreamID
+	if sid == sid%2 { // stream-id overflows
+		s.goAway = 1
+		s.nextStreamIDLock.Unlock()
+		return nil, ErrGoAway
+	}
+	s.nextStreamIDLock.Unlock()
+
+	stream := newStream(sid, s.config.MaxFrameSize, s)
+
+	establish := make(chan struct{})
+	s.establishLock.Lock()
+	if s.establishing == nil {
+		s.establishing = make(map[uint32]chan struct{})
+	}
+	s.establishing[sid] = establish
+	s.establishLock.Unlock()
+
+	if _, err := s.writeControlFrame(newFrame(byte(s.config.Version), cmdSYN, sid)); err != nil {
+		s.forgetEstablishing(sid)
+		return nil, err
+	}
+
+	s.streamLock.Lock()
+	select {
+	case <-s.chSocketReadError:
+		s.streamLock.Unlock()
+		s.forgetEstablishing(sid)
+		return nil, s.socketReadError.Load().(error)
+	case <-s.chSocketWriteError:
+		s.streamLock.Unlock()
+		s.forgetEstablishing(sid)
+		return nil, s.socketWriteError.Load().(error)
+	case <-s.die:
+		s.streamLock.Unlock()
+		s.forgetEstablishing(sid)
+		return nil, io.ErrClosedPipe
+	default:
+		s.streams[sid] = stream
+		s.streamLock.Unlock()
+	}
+
+	select {
+	case <-establish:
+		s.advertiseStreamWindow(sid)
+		wrapper := &Stream{stream: stream}
+		return wrapper, nil
+	case <-ctx.Done():
+		s.forgetEstablishing(sid)
+		s.writeFrameInternal(newFrame(byte(s.config.Version), cmdFIN, sid), nil, CLSCTRL)
+		s.streamClosed(sid)
+		return nil, ctx.Err()
+	case <-s.chSocketReadError:
+		s.forgetEstablishing(sid)
+		return nil, s.socketReadError.Load().(error)
+	case <-s.chSocketWriteError:
+		s.forgetEstablishing(sid)
+		return nil, s.socketWriteError.Load().(error)
+	case <-s.die:
+		s.forgetEstablishing(sid)
+		return nil, io.ErrClosedPipe
+	}
+}
+
+// forgetEstablishing removes the establishment wait channel for sid, if any.
+func (s *Session) forgetEstablishing(sid uint32) {
+	s.establishLock.Lock()
+	delete(s.establishing, sid)
+	s.establishLock.Unlock()
+}
+
+// handleSynAck completes the establishment wait for sid, if OpenStreamContext is waiting on it.
+func (s *Session) handleSynAck(sid uint32) {
+	s.establishLock.Lock()
+	if establish, ok := s.establishing[sid]; ok {
+		close(establish)
+		delete(s.establishing, sid)
+	}
+	s.establishLock.Unlock()
+}
@@ -0,0 +1,42 @@
+// MIT License
+//
+// Copyright (c) 2016-2017 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package smux
+
+import "encoding/binary"
+
+// advertiseStreamWindow sends sid an initial window credit derived from
+// Config.MaxStreamWindowSize, so each stream gets its own backpressure
+// budget instead of only competing for the session-wide token bucket in
+// Config.MaxReceiveBuffer. A slow reader on one stream can then no longer
+// starve every other stream sharing the session.
+func (s *Session) advertiseStreamWindow(sid uint32) {
+	if s.config.MaxStreamWindowSize == 0 {
+		return
+	}
+
+	payload := make([]byte, 8)
+	binary.LittleEndian.PutUint32(payload[4:], s.config.MaxStreamWindowSize)
+	frame := newFrame(byte(s.config.Version), cmdUPD, sid)
+	frame.data = payload
+	s.writeFrameInternal(frame, nil, CLSCTRL)
+}